@@ -0,0 +1,217 @@
+package openldap_exporter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultModule is used when a /probe request does not specify one.
+const DefaultModule = "default"
+
+// TLSConfig controls how a probe connects to a target over TLS/StartTLS.
+type TLSConfig struct {
+	Enable             bool   `yaml:"enable"`
+	StartTLS           bool   `yaml:"starttls"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// tlsConfig builds the *tls.Config used for both ldaps:// dials and
+// StartTLS upgrades from the CA bundle and client cert configured here.
+func (t TLSConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file %s: %w", t.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" && t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client cert/key: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// CustomQuery declares one extra LDAP search to expose as a gauge, so
+// operators can surface a new OpenLDAP counter (e.g. a backend under
+// cn=Databases,cn=Monitor) by editing YAML instead of patching Go source.
+// It is collected the same way as the exporter's built-in queries: one
+// numeric Attr value per matching entry, labeled by the entry's DN.
+type CustomQuery struct {
+	Name   string `yaml:"name"`
+	Help   string `yaml:"help"`
+	BaseDN string `yaml:"base_dn"`
+	Filter string `yaml:"filter"`
+	Attr   string `yaml:"attr"`
+}
+
+// Module describes how to bind to and query one class of LDAP target: the
+// credentials to use, how long to wait, and which replication base DNs (if
+// any) to watch. Targets share a module by pointing at the same name in
+// the /probe request, the way blackbox_exporter shares probe modules.
+type Module struct {
+	User         string        `yaml:"user"`
+	Pass         string        `yaml:"pass"`
+	SASLExternal bool          `yaml:"sasl_external"`
+	Timeout      time.Duration `yaml:"timeout"`
+	TLS          TLSConfig     `yaml:"tls"`
+	Sync         []string      `yaml:"sync"`
+
+	// PassFile and PassEnv let Pass be kept out of the config file
+	// itself, read from a file on disk or an environment variable
+	// instead; LoadConfig resolves whichever is set into Pass. At most
+	// one of Pass/PassFile/PassEnv should be set.
+	PassFile string `yaml:"pass_file"`
+	PassEnv  string `yaml:"pass_env"`
+
+	// Topology lists the replication peers (providers and consumers) to
+	// poll once per scrape for openldap_replication_delay_seconds,
+	// replacing the old single-master ldap_sync_master_addr setting.
+	Topology []Peer `yaml:"topology"`
+
+	// CustomQueries lists additional DN/filter/attribute mappings to
+	// expose as gauges, on top of the exporter's built-in monitor
+	// queries.
+	CustomQueries []CustomQuery `yaml:"custom_queries"`
+}
+
+// TargetConfig pins one target to the module it should be probed with, so
+// a /probe request that omits ?module= still gets the right bind and
+// replication settings. Prometheus file_sd/DNS SD remains the source of
+// truth for which targets exist; this only fills in the module.
+type TargetConfig struct {
+	Target string `yaml:"target"`
+	Module string `yaml:"module"`
+}
+
+// Config is the top-level YAML configuration for the exporter, keyed by
+// module name so a /probe request can select which bind and queries to use.
+type Config struct {
+	Targets []TargetConfig    `yaml:"targets"`
+	Modules map[string]Module `yaml:"modules"`
+}
+
+// moduleForTarget looks target up in c.Targets so a /probe request that
+// omits ?module= falls back to the module pinned for that target in
+// config, instead of always defaulting to DefaultModule.
+func (c *Config) moduleForTarget(target string) string {
+	for _, t := range c.Targets {
+		if t.Target == target {
+			return t.Module
+		}
+	}
+	return DefaultModule
+}
+
+// LoadConfig reads and parses a YAML config file from disk, resolving any
+// pass_file/pass_env credential indirections into each module's Pass.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if cfg.Modules == nil {
+		cfg.Modules = map[string]Module{}
+	}
+	for name, module := range cfg.Modules {
+		pass, err := resolveSecret(module.Pass, module.PassFile, module.PassEnv)
+		if err != nil {
+			return nil, fmt.Errorf("module %q: %w", name, err)
+		}
+		module.Pass = pass
+
+		if err := validateCustomQueries(module.CustomQueries); err != nil {
+			return nil, fmt.Errorf("module %q: %w", name, err)
+		}
+
+		cfg.Modules[name] = module
+	}
+	return cfg, nil
+}
+
+// reservedMetricNames are the exporter's built-in gauge names, which a
+// custom_queries entry must not reuse; reg.MustRegister panics on a
+// collision, so this is caught at config load/reload time instead.
+var reservedMetricNames = map[string]bool{
+	"monitored_object":            true,
+	"monitor_counter_object":      true,
+	"monitor_operation":           true,
+	"monitor_replication":         true,
+	"replication_delay_seconds":   true,
+	"replication_csn_count":       true,
+	"replication_csn_mod":         true,
+	"up":                          true,
+	"scrape_duration_seconds":     true,
+	"connection_reconnects_total": true,
+	"bind_duration_seconds":       true,
+}
+
+// validateCustomQueries rejects custom_queries entries that would collide
+// with a built-in metric name or with each other.
+func validateCustomQueries(queries []CustomQuery) error {
+	seen := map[string]bool{}
+	for _, cq := range queries {
+		if cq.Name == "" {
+			return fmt.Errorf("custom query is missing a name")
+		}
+		if reservedMetricNames[cq.Name] {
+			return fmt.Errorf("custom query %q reuses a built-in metric name", cq.Name)
+		}
+		if seen[cq.Name] {
+			return fmt.Errorf("custom query %q is declared more than once", cq.Name)
+		}
+		seen[cq.Name] = true
+	}
+	return nil
+}
+
+// resolveSecret returns whichever of direct, file, or env is set, reading
+// file from disk or env from the environment. direct wins if more than one
+// is set. An env that names a variable which isn't actually set is an
+// error rather than a silent empty password, so a misconfigured unit file
+// fails config load instead of binding anonymously.
+func resolveSecret(direct, file, env string) (string, error) {
+	switch {
+	case direct != "":
+		return direct, nil
+	case file != "":
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading pass_file %s: %w", file, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case env != "":
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("pass_env %s is not set", env)
+		}
+		return val, nil
+	default:
+		return "", nil
+	}
+}
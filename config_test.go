@@ -0,0 +1,106 @@
+package openldap_exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigPassFile(t *testing.T) {
+	dir := t.TempDir()
+	passFile := filepath.Join(dir, "pass")
+	if err := os.WriteFile(passFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("writing pass_file: %v", err)
+	}
+
+	path := writeConfig(t, `
+modules:
+  default:
+    user: cn=admin,dc=example,dc=com
+    pass_file: `+passFile+`
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.Modules["default"].Pass; got != "s3cret" {
+		t.Errorf("Pass = %q, want %q (trimmed)", got, "s3cret")
+	}
+}
+
+func TestLoadConfigPassEnv(t *testing.T) {
+	t.Setenv("OPENLDAP_EXPORTER_TEST_PASS", "envsecret")
+
+	path := writeConfig(t, `
+modules:
+  default:
+    pass_env: OPENLDAP_EXPORTER_TEST_PASS
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.Modules["default"].Pass; got != "envsecret" {
+		t.Errorf("Pass = %q, want %q", got, "envsecret")
+	}
+}
+
+func TestLoadConfigPassEnvUnset(t *testing.T) {
+	path := writeConfig(t, `
+modules:
+  default:
+    pass_env: OPENLDAP_EXPORTER_TEST_PASS_NOT_SET
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for an unset pass_env, got nil")
+	}
+}
+
+func TestLoadConfigRejectsReservedCustomQueryName(t *testing.T) {
+	path := writeConfig(t, `
+modules:
+  default:
+    custom_queries:
+      - name: up
+        base_dn: cn=Databases,cn=Monitor
+        filter: (objectClass=*)
+        attr: monitoredInfo
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for a custom query reusing a built-in metric name, got nil")
+	}
+}
+
+func TestLoadConfigRejectsDuplicateCustomQueryName(t *testing.T) {
+	path := writeConfig(t, `
+modules:
+  default:
+    custom_queries:
+      - name: backend_monitor
+        base_dn: cn=Databases,cn=Monitor
+        filter: (objectClass=*)
+        attr: monitoredInfo
+      - name: backend_monitor
+        base_dn: cn=Databases,cn=Monitor
+        filter: (objectClass=*)
+        attr: monitorOpCompleted
+`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected an error for a custom query declared twice, got nil")
+	}
+}
@@ -0,0 +1,51 @@
+package openldap_exporter
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewLogger builds the process-wide slog.Logger for the given
+// --log.level/--log.format pair.
+func NewLogger(level, format string) *slog.Logger {
+	return newLogger(level, format, os.Stderr)
+}
+
+func newLogger(level, format string, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// LogFlags registers --log.level and --log.format on fs and returns a func
+// that builds the resulting logger once fs.Parse has run. fs is normally
+// flag.CommandLine, passed in so tests can use a private FlagSet instead.
+func LogFlags(fs *flag.FlagSet) func() *slog.Logger {
+	level := fs.String("log.level", "info", "Only log messages with the given severity or above. One of: [debug, info, warn, error]")
+	format := fs.String("log.format", "logfmt", "Output format of log messages. One of: [logfmt, json]")
+	return func() *slog.Logger {
+		return NewLogger(*level, *format)
+	}
+}
@@ -0,0 +1,59 @@
+package openldap_exporter
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ProbeHandler implements a blackbox_exporter-style /probe endpoint: it
+// dials the requested target on demand, scrapes it into a registry created
+// just for this request, and serves the result as that request's /metrics
+// response. This lets one exporter process monitor an entire fleet of
+// OpenLDAP replicas discovered via Prometheus file_sd or DNS SD, instead of
+// requiring one long-lived Scraper per target.
+//
+// configs is called on every request rather than captured once, so a
+// config reloaded by ConfigManager (via SIGHUP or /-/reload) takes effect
+// on the next probe without restarting the handler.
+func ProbeHandler(configs func() *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+
+		target := params.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		cfg := configs()
+
+		moduleName := params.Get("module")
+		if moduleName == "" {
+			moduleName = cfg.moduleForTarget(target)
+		}
+		module, ok := cfg.Modules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		probeSuccessGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_success",
+			Help: "Whether the probe of this target succeeded",
+		})
+		registry.MustRegister(probeSuccessGauge)
+
+		ts := newTargetScraper(target, module, registry)
+		if ts.scrape() {
+			probeSuccessGauge.Set(1)
+		} else {
+			probeSuccessGauge.Set(0)
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,64 @@
+package openldap_exporter
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeHandlerMissingTarget(t *testing.T) {
+	handler := ProbeHandler(func() *Config { return &Config{} })
+
+	req := httptest.NewRequest(http.MethodGet, "/probe", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rec.Body.String(), "target parameter is missing") {
+		t.Errorf("body = %q, want it to mention the missing target parameter", rec.Body.String())
+	}
+}
+
+func TestProbeHandlerUnknownModule(t *testing.T) {
+	handler := ProbeHandler(func() *Config { return &Config{Modules: map[string]Module{}} })
+
+	req := httptest.NewRequest(http.MethodGet, "/probe?target=ldap.example.com:389&module=missing", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rec.Body.String(), `unknown module "missing"`) {
+		t.Errorf("body = %q, want it to mention the unknown module", rec.Body.String())
+	}
+}
+
+func TestProbeHandlerFailedProbeReportsZero(t *testing.T) {
+	// Bind a port and close it immediately so dialing it is refused right
+	// away instead of needing a real LDAP server or a slow timeout.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	cfg := &Config{Modules: map[string]Module{DefaultModule: {}}}
+	handler := ProbeHandler(func() *Config { return cfg })
+
+	req := httptest.NewRequest(http.MethodGet, "/probe?target="+addr, nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "probe_success 0") {
+		t.Errorf("body = %q, want a probe_success 0 gauge", rec.Body.String())
+	}
+}
@@ -0,0 +1,85 @@
+package openldap_exporter
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// ConfigManager holds the exporter's live Config and lets it be swapped
+// atomically without dropping the metrics server. Readers call Config() on
+// every request (see ProbeHandler); a reload only ever replaces the
+// pointer, so in-flight probes keep using whichever Config they already
+// loaded.
+type ConfigManager struct {
+	path string
+	cfg  atomic.Pointer[Config]
+	log  *slog.Logger
+}
+
+// NewConfigManager loads path and returns a ConfigManager serving it, or
+// an error if the initial load fails.
+func NewConfigManager(path string, logger *slog.Logger) (*ConfigManager, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cm := &ConfigManager{path: path, log: logger.With("component", "config_manager", "path", path)}
+	if err := cm.Reload(); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// Config returns the currently active configuration.
+func (c *ConfigManager) Config() *Config {
+	return c.cfg.Load()
+}
+
+// Reload re-reads and re-parses the config file, swapping it in only if
+// it parses successfully so a bad edit can't take down a running exporter.
+func (c *ConfigManager) Reload() error {
+	cfg, err := LoadConfig(c.path)
+	if err != nil {
+		return err
+	}
+	c.cfg.Store(cfg)
+	return nil
+}
+
+// ReloadHandler serves /-/reload: a POST/PUT triggers Reload, responding
+// 200 on success or 500 with the parse error otherwise.
+func (c *ConfigManager) ReloadHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			http.Error(w, "this endpoint requires a POST or PUT request", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := c.Reload(); err != nil {
+			c.log.Error("config reload failed", "error", err)
+			http.Error(w, fmt.Sprintf("reload failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		c.log.Info("config reloaded")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HandleSIGHUP starts a goroutine that reloads the config on every SIGHUP,
+// for operators who prefer a signal over curl'ing /-/reload.
+func (c *ConfigManager) HandleSIGHUP() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := c.Reload(); err != nil {
+				c.log.Error("config reload failed", "error", err)
+				continue
+			}
+			c.log.Info("config reloaded")
+		}
+	}()
+}
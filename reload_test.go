@@ -0,0 +1,114 @@
+package openldap_exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeReloadTestConfig(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("writing test config: %v", err)
+	}
+}
+
+func TestConfigManagerReloadSwapsOnSuccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=first\n")
+
+	cm, err := NewConfigManager(path, nil)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+	if got := cm.Config().Modules[DefaultModule].User; got != "cn=first" {
+		t.Fatalf("initial User = %q, want cn=first", got)
+	}
+
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=second\n")
+	if err := cm.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := cm.Config().Modules[DefaultModule].User; got != "cn=second" {
+		t.Fatalf("User after reload = %q, want cn=second", got)
+	}
+}
+
+func TestConfigManagerReloadKeepsOldConfigOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=first\n")
+
+	cm, err := NewConfigManager(path, nil)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+
+	writeReloadTestConfig(t, path, "not: [valid: yaml")
+	if err := cm.Reload(); err == nil {
+		t.Fatal("expected Reload to fail on invalid YAML, got nil")
+	}
+	if got := cm.Config().Modules[DefaultModule].User; got != "cn=first" {
+		t.Fatalf("User after failed reload = %q, want the old value cn=first", got)
+	}
+}
+
+func TestReloadHandlerMethodNotAllowed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadTestConfig(t, path, "modules: {}\n")
+	cm, err := NewConfigManager(path, nil)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/-/reload", nil)
+	rec := httptest.NewRecorder()
+	cm.ReloadHandler()(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestReloadHandlerSuccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=first\n")
+	cm, err := NewConfigManager(path, nil)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=second\n")
+	req := httptest.NewRequest(http.MethodPost, "/-/reload", nil)
+	rec := httptest.NewRecorder()
+	cm.ReloadHandler()(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got := cm.Config().Modules[DefaultModule].User; got != "cn=second" {
+		t.Fatalf("User after reload = %q, want cn=second", got)
+	}
+}
+
+func TestReloadHandlerFailureKeepsOldConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	writeReloadTestConfig(t, path, "modules:\n  default:\n    user: cn=first\n")
+	cm, err := NewConfigManager(path, nil)
+	if err != nil {
+		t.Fatalf("NewConfigManager: %v", err)
+	}
+
+	writeReloadTestConfig(t, path, "not: [valid: yaml")
+	req := httptest.NewRequest(http.MethodPost, "/-/reload", nil)
+	rec := httptest.NewRecorder()
+	cm.ReloadHandler()(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := cm.Config().Modules[DefaultModule].User; got != "cn=first" {
+		t.Fatalf("User after failed reload = %q, want the old value cn=first", got)
+	}
+}
@@ -3,15 +3,22 @@ package openldap_exporter
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
-	"gopkg.in/ldap.v2"
 )
 
+// defaultQueryTimeout bounds each LDAP search when a Scraper isn't given
+// an explicit Timeout, so a stuck target can't hang a /metrics scrape.
+const defaultQueryTimeout = 10 * time.Second
+
 const (
 	baseDN    = "cn=Monitor"
 	opsBaseDN = "cn=Operations,cn=Monitor"
@@ -29,95 +36,20 @@ const (
 	monitorReplication       = "monitorReplication"
 )
 
+// query and its setData variants remain used by the per-/probe-request
+// scrapes in target.go, which register a throwaway GaugeVec per request
+// instead of building prometheus.Metric values directly.
 type query struct {
-	baseDN           string
-	searchFilter     string
-	searchAttr       string
-	metric           *prometheus.GaugeVec
-	setData          func([]*ldap.Entry, *query)
-	RepolicateResult float64 // to save repolicate nodes update time
-}
-
-var (
-	monitoredObjectGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Subsystem: "openldap",
-			Name:      "monitored_object",
-			Help:      help(baseDN, objectClass(monitoredObject), monitoredInfo),
-		},
-		[]string{"dn"},
-	)
-	monitorCounterObjectGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Subsystem: "openldap",
-			Name:      "monitor_counter_object",
-			Help:      help(baseDN, objectClass(monitorCounterObject), monitorCounter),
-		},
-		[]string{"dn"},
-	)
-	monitorOperationGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Subsystem: "openldap",
-			Name:      "monitor_operation",
-			Help:      help(opsBaseDN, objectClass(monitorOperation), monitorOpCompleted),
-		},
-		[]string{"dn"},
-	)
-	scrapeCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Subsystem: "openldap",
-			Name:      "scrape",
-			Help:      "successful vs unsuccessful ldap scrape attempts",
-		},
-		[]string{"result"},
-	)
-	monitorReplicationGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Subsystem: "openldap",
-			Name:      "monitor_replication",
-			Help:      help(baseDN, monitorReplication),
-		},
-		[]string{"id", "type"},
-	)
-	queries = []*query{
-		{
-			baseDN:       baseDN,
-			searchFilter: objectClass(monitoredObject),
-			searchAttr:   monitoredInfo,
-			metric:       monitoredObjectGauge,
-			setData:      setValue,
-		}, {
-			baseDN:       baseDN,
-			searchFilter: objectClass(monitorCounterObject),
-			searchAttr:   monitorCounter,
-			metric:       monitorCounterObjectGauge,
-			setData:      setValue,
-		},
-		{
-			baseDN:       opsBaseDN,
-			searchFilter: objectClass(monitorOperation),
-			searchAttr:   monitorOpCompleted,
-			metric:       monitorOperationGauge,
-			setData:      setValue,
-		},
-		{
-			baseDN:       opsBaseDN,
-			searchFilter: objectClass(monitorOperation),
-			searchAttr:   monitorOpCompleted,
-			metric:       monitorOperationGauge,
-			setData:      setValue,
-		},
-	}
-)
-
-func init() {
-	prometheus.MustRegister(
-		monitoredObjectGauge,
-		monitorCounterObjectGauge,
-		monitorOperationGauge,
-		monitorReplicationGauge,
-		scrapeCounter,
-	)
+	baseDN       string
+	searchFilter string
+	searchAttr   string
+	metric       *prometheus.GaugeVec
+	setData      func([]*ldap.Entry, *query)
+
+	// logger is used by setData variants that need to warn about
+	// malformed attribute values (currently just setReplicationValue).
+	// It falls back to slog.Default() when left nil.
+	logger *slog.Logger
 }
 
 func help(msg ...string) string {
@@ -144,27 +76,36 @@ func setValue(entries []*ldap.Entry, q *query) {
 	}
 }
 
-// parse ldap contextCSN column
-func parseContextCSN(val string, fields log.Fields) (gt, count float64, sid string, mod float64, err error) {
+// parseContextCSN parses an ldap contextCSN column, logging via logger
+// (falling back to slog.Default() if nil) when a field doesn't parse.
+func parseContextCSN(logger *slog.Logger, val, filter, attr string) (gt, count float64, sid string, mod float64, err error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
 	valueBuffer := strings.Split(val, "#")
+	if len(valueBuffer) != 4 {
+		err = fmt.Errorf("expected 4 #-delimited fields, got %d", len(valueBuffer))
+		logger.Warn("unexpected contextCSN value", "filter", filter, "attr", attr, "value", val, "error", err)
+		return
+	}
 
 	t, err := time.Parse("20060102150405.999999Z", valueBuffer[0])
 	gt = float64(t.Unix())
 
 	if err != nil {
-		log.WithFields(fields).WithError(err).Warn("unexpected gt value")
+		logger.Warn("unexpected gt value", "filter", filter, "attr", attr, "value", val, "error", err)
 		return
 	}
 
 	count, err = strconv.ParseFloat(valueBuffer[1], 64)
 	if err != nil {
-		log.WithFields(fields).WithError(err).Warn("unexpected count value")
+		logger.Warn("unexpected count value", "filter", filter, "attr", attr, "value", val, "error", err)
 		return
 	}
 	sid = valueBuffer[2]
 	mod, err = strconv.ParseFloat(valueBuffer[3], 64)
 	if err != nil {
-		log.WithFields(fields).WithError(err).Warn("unexpected mod value")
+		logger.Warn("unexpected mod value", "filter", filter, "attr", attr, "value", val, "error", err)
 		return
 	}
 
@@ -173,206 +114,478 @@ func parseContextCSN(val string, fields log.Fields) (gt, count float64, sid stri
 
 func setReplicationValue(entries []*ldap.Entry, q *query) {
 	for _, entry := range entries {
-		val := entry.GetAttributeValue(q.searchAttr)
-		if val == "" {
-			// not every entry will have this attribute
-			continue
+		// contextCSN is multi-valued on a multi-master/multi-provider
+		// suffix, one value per sid; GetAttributeValue would silently
+		// keep only the first.
+		for _, val := range entry.GetAttributeValues(q.searchAttr) {
+			gt, count, sid, mod, err := parseContextCSN(q.logger, val, q.searchFilter, q.searchAttr)
+			if err != nil {
+				continue
+			}
+
+			q.metric.WithLabelValues(sid, "gt").Set(gt)
+			q.metric.WithLabelValues(sid, "count").Set(count)
+			q.metric.WithLabelValues(sid, "mod").Set(mod)
 		}
-		fields := log.Fields{
-			"filter": q.searchFilter,
-			"attr":   q.searchAttr,
-			"value":  val,
+	}
+}
+
+// searchEntries runs a single LDAP search bounded by ctx and returns its
+// entries, shared by the throwaway per-probe queries and the metricQuery
+// collector path. go-ldap/v3's Conn has no context-aware Search, so ctx is
+// honored by closing conn if it's done before the search returns; Scraper's
+// dropConn/redial handling takes it from there, and the one-shot callers
+// close their conn anyway.
+func searchEntries(ctx context.Context, conn *ldap.Conn, baseDN, searchFilter, searchAttr string) ([]*ldap.Entry, error) {
+	req := ldap.NewSearchRequest(
+		baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		searchFilter, []string{searchAttr}, nil,
+	)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// done may already be closed too if this goroutine ran late;
+			// select would then pick at random, so check it first.
+			select {
+			case <-done:
+			default:
+				conn.Close()
+			}
+		case <-done:
 		}
+	}()
 
-		gt, count, sid, mod, err := parseContextCSN(val, fields)
+	sr, err := conn.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	return sr.Entries, nil
+}
+
+func runQuery(ctx context.Context, conn *ldap.Conn, q *query) error {
+	entries, err := searchEntries(ctx, conn, q.baseDN, q.searchFilter, q.searchAttr)
+	if err != nil {
+		return err
+	}
+	q.setData(entries, q)
+	return nil
+}
+
+// tlsDialScheme returns the URL scheme dialLDAP should actually use for
+// netScheme given tlsCfg. go-ldap only wraps the dial in TLS for an
+// "ldaps" scheme; it ignores DialWithTLSConfig entirely for "ldap", so
+// tls.enable without starttls must force the scheme or the connection
+// would silently go out in the clear. It errors if netScheme is some
+// other scheme (e.g. "cldap") that implicit TLS can't be layered onto.
+func tlsDialScheme(netScheme string, tlsCfg TLSConfig) (string, error) {
+	if !tlsCfg.Enable || tlsCfg.StartTLS {
+		return netScheme, nil
+	}
+	switch netScheme {
+	case "", "ldap":
+		return "ldaps", nil
+	case "ldaps":
+		return netScheme, nil
+	default:
+		return "", fmt.Errorf("tls.enable requires an ldap or ldaps target, got scheme %q", netScheme)
+	}
+}
+
+// dialLDAP opens a connection to addr using netScheme ("ldap" or "ldaps"),
+// applies TLS/StartTLS from tlsCfg, and binds via SASL EXTERNAL or a simple
+// bind. It is shared by Scraper's pooled connections and the one-shot
+// probes in target.go, so both paths support the same TLS/bind options.
+func dialLDAP(netScheme, addr string, tlsCfg TLSConfig, saslExternal bool, user, pass string) (*ldap.Conn, time.Duration, error) {
+	netScheme, err := tlsDialScheme(netScheme, tlsCfg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	url := fmt.Sprintf("%s://%s", netScheme, addr)
+
+	var opts []ldap.DialOpt
+	if tlsCfg.Enable && !tlsCfg.StartTLS {
+		tc, err := tlsCfg.tlsConfig()
 		if err != nil {
-			continue
+			return nil, 0, fmt.Errorf("tls config: %w", err)
 		}
+		opts = append(opts, ldap.DialWithTLSConfig(tc))
+	}
 
-		// save repolicate result
-		q.RepolicateResult = gt
+	conn, err := ldap.DialURL(url, opts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("dial %s: %w", url, err)
+	}
 
-		q.metric.WithLabelValues(sid, "gt").Set(gt)
-		q.metric.WithLabelValues(sid, "count").Set(count)
-		q.metric.WithLabelValues(sid, "mod").Set(mod)
+	if tlsCfg.Enable && tlsCfg.StartTLS {
+		tc, err := tlsCfg.tlsConfig()
+		if err != nil {
+			conn.Close()
+			return nil, 0, fmt.Errorf("tls config: %w", err)
+		}
+		if err := conn.StartTLS(tc); err != nil {
+			conn.Close()
+			return nil, 0, fmt.Errorf("starttls %s: %w", url, err)
+		}
+	}
+
+	start := time.Now()
+	switch {
+	case saslExternal:
+		err = conn.ExternalBind()
+	case user != "" && pass != "":
+		err = conn.Bind(user, pass)
 	}
+	bindDuration := time.Since(start)
+	if err != nil {
+		conn.Close()
+		return nil, bindDuration, fmt.Errorf("bind: %w", err)
+	}
+	return conn, bindDuration, nil
+}
+
+// metricQuery is one LDAP search backing a Scraper.Collect call. Unlike
+// query above, it has no gauge of its own: its desc and collect func build
+// prometheus.Metric values on the fly so Scraper can implement
+// prometheus.Collector and be queried lazily on every scrape, the way
+// node_exporter's collectors do, instead of writing into long-lived state.
+type metricQuery struct {
+	baseDN       string
+	searchFilter string
+	searchAttr   string
+	desc         *prometheus.Desc
+	collect      func([]*ldap.Entry, *metricQuery, chan<- prometheus.Metric)
+	logger       *slog.Logger
+}
+
+func monitoredObjectDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "monitored_object"),
+		help(baseDN, objectClass(monitoredObject), monitoredInfo),
+		[]string{"dn"}, nil,
+	)
+}
+
+func monitorCounterObjectDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "monitor_counter_object"),
+		help(baseDN, objectClass(monitorCounterObject), monitorCounter),
+		[]string{"dn"}, nil,
+	)
+}
+
+func monitorOperationDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "monitor_operation"),
+		help(opsBaseDN, objectClass(monitorOperation), monitorOpCompleted),
+		[]string{"dn"}, nil,
+	)
+}
+
+func monitorReplicationDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "monitor_replication"),
+		help(baseDN, monitorReplication),
+		[]string{"id", "type"}, nil,
+	)
+}
+
+// customQueryDesc builds the Desc for a user-configured CustomQuery,
+// collected the same way as the built-in monitor queries (one numeric
+// value per entry, labeled by DN).
+func customQueryDesc(cq CustomQuery) *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", cq.Name),
+		customQueryHelp(cq),
+		[]string{"dn"}, nil,
+	)
 }
 
-func setReplicationDelayValue(entries []*ldap.Entry, q *query) {
+// customQueryHelp uses the operator-supplied Help if set, falling back to
+// the same base_dn/filter/attr summary the built-in Desc funcs use.
+func customQueryHelp(cq CustomQuery) string {
+	if cq.Help != "" {
+		return cq.Help
+	}
+	return help(cq.BaseDN, cq.Filter, cq.Attr)
+}
+
+func upDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "up"),
+		"Whether the last scrape of the target succeeded",
+		nil, nil,
+	)
+}
+
+func scrapeDurationDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "", "scrape_duration_seconds"),
+		"How long the last scrape of the target took",
+		nil, nil,
+	)
+}
+
+func connectionReconnectsDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "connection", "reconnects_total"),
+		"Total number of times the pooled LDAP connection was dropped and redialed",
+		nil, nil,
+	)
+}
+
+func bindDurationDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "bind", "duration_seconds"),
+		"How long the most recent LDAP bind took",
+		nil, nil,
+	)
+}
+
+func collectAttrValue(entries []*ldap.Entry, q *metricQuery, ch chan<- prometheus.Metric) {
 	for _, entry := range entries {
 		val := entry.GetAttributeValue(q.searchAttr)
 		if val == "" {
 			// not every entry will have this attribute
 			continue
 		}
-		fields := log.Fields{
-			"filter": q.searchFilter,
-			"attr":   q.searchAttr,
-			"value":  val,
-		}
-		valueBuffer := strings.Split(val, "#")
-		gt, err := time.Parse("20060102150405.999999Z", valueBuffer[0])
+		num, err := strconv.ParseFloat(val, 64)
 		if err != nil {
-			log.WithFields(fields).WithError(err).Warn("unexpected gt value")
+			// some of these attributes are not numbers
 			continue
 		}
-		sid := valueBuffer[2]
-
-		q.metric.WithLabelValues(sid, "gt").Set(float64(gt.Unix()))
+		ch <- prometheus.MustNewConstMetric(q.desc, prometheus.GaugeValue, num, entry.DN)
 	}
 }
 
-type Scraper struct {
-	Net                string
-	Addr               string
-	User               string
-	Pass               string
-	Tick               time.Duration
-	LdapSync           []string
-	log                log.FieldLogger
-	Sync               []string
-	LdapSyncTimeDetal  bool
-	LdapSyncMasterAddr string
-}
-
-func (s *Scraper) addReplicationQueries() {
-	for _, q := range s.Sync {
-		queries = append(queries,
-			&query{
-				baseDN:       q,
-				searchFilter: objectClass("*"),
-				searchAttr:   monitorReplicationFilter,
-				metric:       monitorReplicationGauge,
-				setData:      setReplicationValue,
-			},
-		)
-	}
-}
-
-func (s *Scraper) Start(ctx context.Context) {
-	s.log = log.WithField("component", "scraper")
-	s.addReplicationQueries()
-	address := fmt.Sprintf("%s://%s", s.Net, s.Addr)
-	s.log.WithField("addr", address).Info("starting monitor loop")
-	ticker := time.NewTicker(s.Tick)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			s.runOnce()
-		case <-ctx.Done():
-			return
+func collectReplicationValue(entries []*ldap.Entry, q *metricQuery, ch chan<- prometheus.Metric) {
+	for _, entry := range entries {
+		// contextCSN is multi-valued on a multi-master/multi-provider
+		// suffix, one value per sid; GetAttributeValue would silently
+		// keep only the first.
+		for _, val := range entry.GetAttributeValues(q.searchAttr) {
+			gt, count, sid, mod, err := parseContextCSN(q.logger, val, q.searchFilter, q.searchAttr)
+			if err != nil {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(q.desc, prometheus.GaugeValue, gt, sid, "gt")
+			ch <- prometheus.MustNewConstMetric(q.desc, prometheus.GaugeValue, count, sid, "count")
+			ch <- prometheus.MustNewConstMetric(q.desc, prometheus.GaugeValue, mod, sid, "mod")
 		}
 	}
 }
 
-func (s *Scraper) runOnce() {
-	result := "fail"
-	if s.scrape() {
-		result = "ok"
-	}
-	scrapeCounter.WithLabelValues(result).Inc()
+// Scraper implements prometheus.Collector, querying Addr over a pooled
+// connection once per Collect call instead of running its own ticker
+// loop; it dials only when no pooled connection is open. That makes LDAP
+// queried lazily on every /metrics scrape rather than on a fixed interval
+// that may drift from Prometheus's own scrape schedule.
+type Scraper struct {
+	Net  string
+	Addr string
+	User string
+	Pass string
+
+	// SASLExternal binds via SASL EXTERNAL (e.g. a TLS client cert)
+	// instead of User/Pass.
+	SASLExternal bool
+	TLS          TLSConfig
+	// Timeout bounds each LDAP search against Addr, and the topology pass
+	// as a whole; it defaults to defaultQueryTimeout when zero.
+	Timeout time.Duration
+
+	// Sync lists this server's own base DNs to watch for contextCSN
+	// (gt/count/mod only; cross-peer delay is Topology's job).
+	Sync []string
+
+	// Topology declares the full multi-provider/multi-consumer mesh to
+	// poll for replication delay, superseding the old single
+	// provider/single consumer LdapSyncMasterAddr design.
+	Topology ReplicationTopology
+
+	// CustomQueries lists additional DN/filter/attribute mappings to
+	// collect on top of the built-in monitor queries, so new OpenLDAP
+	// counters can be exposed by editing config instead of this file.
+	CustomQueries []CustomQuery
+
+	// Log is injected by the caller so tests (and multi-target setups)
+	// can capture or silence it; it defaults to slog.Default() otherwise.
+	Log *slog.Logger
+
+	connMu sync.Mutex
+	conn   *ldap.Conn
+
+	// scrapeMu serializes scrape() so two concurrent Collect calls (e.g.
+	// two Prometheus replicas scraping the same exporter) can't have one
+	// drop the pooled conn out from under the other's in-flight Search.
+	scrapeMu sync.Mutex
+
+	reconnects           uint64
+	lastBindDurationBits uint64
 }
 
-func (s *Scraper) scrape() bool {
-	conn, err := ldap.Dial(s.Net, s.Addr)
-	if err != nil {
-		s.log.WithError(err).Error("dial failed")
-		return false
+func (s *Scraper) logger() *slog.Logger {
+	logger := s.Log
+	if logger == nil {
+		logger = slog.Default()
 	}
-	defer conn.Close()
+	return logger.With("component", "scraper", "target", fmt.Sprintf("%s://%s", s.Net, s.Addr))
+}
 
-	if s.User != "" && s.Pass != "" {
-		err = conn.Bind(s.User, s.Pass)
-		if err != nil {
-			s.log.WithError(err).Error("bind failed")
-			return false
-		}
+func (s *Scraper) queryTimeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
 	}
+	return defaultQueryTimeout
+}
 
-	ret := true
-	for _, q := range queries {
-		if err := s.scrapeQuery(conn, q); err != nil {
-
-			s.log.WithError(err).WithField("filter", q.searchFilter).WithField("base_dn", q.baseDN).Warn("query failed")
-			ret = false
-		}
+func (s *Scraper) bindDurationSeconds() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.lastBindDurationBits))
+}
 
-		// add replicate nodes delay to master metrics
-		if q.searchAttr == monitorReplicationFilter {
-
-			if s.LdapSyncTimeDetal {
-				sr, err := s.queryMasterContext(q)
-				if err != nil {
-					s.log.WithError(err).Error("query master context error")
-					return false
-				}
-
-				if len(sr.Entries) <= 0 {
-					s.log.Error("get master context csn error ,result empty")
-					return false
-				}
-
-				// only handle the first entrie
-				entry := sr.Entries[0]
-				val := entry.GetAttributeValue(q.searchAttr)
-				if val == "" {
-					// not every entry will have this attribute
-					continue
-				}
-
-				valueBuffer := strings.Split(val, "#")
-				gt, err := time.Parse("20060102150405.999999Z", valueBuffer[0])
-				if err != nil {
-					s.log.WithError(err).Error("time parser error,value=%s", valueBuffer[0])
-					return false
-				}
-				sid := valueBuffer[2]
-
-				// add delay to master metric
-				q.metric.WithLabelValues(sid, "delay").Set(float64(gt.Unix()) - q.RepolicateResult)
-			}
+// dialAddr opens and binds a new connection to addr, applying TLS/StartTLS
+// and SASL EXTERNAL/simple bind according to the Scraper's configuration.
+func (s *Scraper) dialAddr(addr string) (*ldap.Conn, error) {
+	conn, bindDuration, err := dialLDAP(s.Net, addr, s.TLS, s.SASLExternal, s.User, s.Pass)
+	atomic.StoreUint64(&s.lastBindDurationBits, math.Float64bits(bindDuration.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
 
-		}
+// getConn returns the pooled connection to Addr, dialing it if this is the
+// first call or a previous query dropped it.
+func (s *Scraper) getConn() (*ldap.Conn, error) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := s.dialAddr(s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
 
+// dropConn closes and discards the pooled connection so the next getConn
+// call redials, and counts the reconnect.
+func (s *Scraper) dropConn() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+		atomic.AddUint64(&s.reconnects, 1)
 	}
+}
 
-	return ret
+func (s *Scraper) queries() []*metricQuery {
+	qs := []*metricQuery{
+		{baseDN: baseDN, searchFilter: objectClass(monitoredObject), searchAttr: monitoredInfo,
+			desc: monitoredObjectDesc(), collect: collectAttrValue},
+		{baseDN: baseDN, searchFilter: objectClass(monitorCounterObject), searchAttr: monitorCounter,
+			desc: monitorCounterObjectDesc(), collect: collectAttrValue},
+		{baseDN: opsBaseDN, searchFilter: objectClass(monitorOperation), searchAttr: monitorOpCompleted,
+			desc: monitorOperationDesc(), collect: collectAttrValue},
+	}
+	for _, base := range s.Sync {
+		qs = append(qs, &metricQuery{
+			baseDN:       base,
+			searchFilter: objectClass("*"),
+			searchAttr:   monitorReplicationFilter,
+			desc:         monitorReplicationDesc(),
+			collect:      collectReplicationValue,
+			logger:       s.logger(),
+		})
+	}
+	for _, cq := range s.CustomQueries {
+		qs = append(qs, &metricQuery{
+			baseDN:       cq.BaseDN,
+			searchFilter: cq.Filter,
+			searchAttr:   cq.Attr,
+			desc:         customQueryDesc(cq),
+			collect:      collectAttrValue,
+		})
+	}
+	return qs
 }
 
-func (s *Scraper) scrapeQuery(conn *ldap.Conn, q *query) error {
-	req := ldap.NewSearchRequest(
-		q.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		q.searchFilter, []string{q.searchAttr}, nil,
-	)
-	sr, err := conn.Search(req)
-	if err != nil {
-		return err
+// Describe implements prometheus.Collector.
+func (s *Scraper) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upDesc()
+	ch <- scrapeDurationDesc()
+	ch <- connectionReconnectsDesc()
+	ch <- bindDurationDesc()
+	ch <- replicationDelayDesc()
+	ch <- replicationCSNCountDesc()
+	ch <- replicationCSNModDesc()
+	for _, q := range s.queries() {
+		ch <- q.desc
 	}
-	q.setData(sr.Entries, q)
-	return nil
 }
 
-func (s *Scraper) queryMasterContext(q *query) (result *ldap.SearchResult, err error) {
-	masterConn, err := ldap.Dial(s.Net, s.LdapSyncMasterAddr)
-	if err != nil {
-		s.log.WithError(err).Error("dial master node failed")
-		return
+// Collect implements prometheus.Collector, querying Addr (over a pooled,
+// automatically-reconnecting connection) and emitting its metrics along
+// with an up gauge and the scrape's own duration.
+func (s *Scraper) Collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	success := s.scrape(ch)
+
+	up := 0.0
+	if success {
+		up = 1.0
 	}
+	ch <- prometheus.MustNewConstMetric(upDesc(), prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(scrapeDurationDesc(), prometheus.GaugeValue, time.Since(start).Seconds())
+	ch <- prometheus.MustNewConstMetric(connectionReconnectsDesc(), prometheus.CounterValue, float64(atomic.LoadUint64(&s.reconnects)))
+	ch <- prometheus.MustNewConstMetric(bindDurationDesc(), prometheus.GaugeValue, s.bindDurationSeconds())
+}
 
-	defer masterConn.Close()
+func (s *Scraper) scrape(ch chan<- prometheus.Metric) bool {
+	s.scrapeMu.Lock()
+	defer s.scrapeMu.Unlock()
 
-	err = masterConn.Bind(s.User, s.Pass)
+	conn, err := s.getConn()
 	if err != nil {
-		s.log.WithError(err).Error("bind master failed")
-		return
+		s.logger().Error("dial failed", "error", err)
+		return false
 	}
 
-	req := ldap.NewSearchRequest(
-		q.baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		q.searchFilter, []string{q.searchAttr}, nil,
-	)
+	ok := true
+	for _, q := range s.queries() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout())
+		err := s.runMetricQuery(ctx, conn, q, ch)
+		cancel()
+		if err != nil {
+			s.logger().Warn("query failed", "filter", q.searchFilter, "base_dn", q.baseDN, "error", err)
+			s.dropConn()
+			ok = false
+		}
+	}
 
-	return masterConn.Search(req)
+	if len(s.Topology.Peers) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), s.queryTimeout())
+		defer cancel()
+		if !s.Topology.collect(ctx, s.Sync, s.logger(), ch) {
+			ok = false
+		}
+	}
+
+	return ok
+}
 
+func (s *Scraper) runMetricQuery(ctx context.Context, conn *ldap.Conn, q *metricQuery, ch chan<- prometheus.Metric) error {
+	entries, err := searchEntries(ctx, conn, q.baseDN, q.searchFilter, q.searchAttr)
+	if err != nil {
+		return err
+	}
+	q.collect(entries, q, ch)
+	return nil
 }
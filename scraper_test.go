@@ -0,0 +1,38 @@
+package openldap_exporter
+
+import "testing"
+
+func TestTLSDialScheme(t *testing.T) {
+	cases := []struct {
+		name      string
+		netScheme string
+		tlsCfg    TLSConfig
+		want      string
+		wantErr   bool
+	}{
+		{name: "tls disabled keeps ldap", netScheme: "ldap", tlsCfg: TLSConfig{}, want: "ldap"},
+		{name: "starttls keeps ldap", netScheme: "ldap", tlsCfg: TLSConfig{Enable: true, StartTLS: true}, want: "ldap"},
+		{name: "implicit tls upgrades bare ldap", netScheme: "ldap", tlsCfg: TLSConfig{Enable: true}, want: "ldaps"},
+		{name: "implicit tls upgrades empty scheme", netScheme: "", tlsCfg: TLSConfig{Enable: true}, want: "ldaps"},
+		{name: "implicit tls keeps ldaps", netScheme: "ldaps", tlsCfg: TLSConfig{Enable: true}, want: "ldaps"},
+		{name: "implicit tls rejects incompatible scheme", netScheme: "cldap", tlsCfg: TLSConfig{Enable: true}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tlsDialScheme(tc.netScheme, tc.tlsCfg)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got scheme %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got scheme %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package openldap_exporter
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// targetScraper is a one-shot Scraper bound to a single target and a
+// registry created for one /probe request. Unlike the long-lived Scraper
+// collector, its gauges are discarded with the request instead of
+// accumulating series across an entire fleet of targets.
+type targetScraper struct {
+	net, addr    string
+	user, pass   string
+	saslExternal bool
+	tls          TLSConfig
+	timeout      time.Duration
+
+	sync     []string
+	topology ReplicationTopology
+
+	queries        []*query
+	replDelayGauge *prometheus.GaugeVec
+	csnCountGauge  *prometheus.GaugeVec
+	csnModGauge    *prometheus.GaugeVec
+
+	log *slog.Logger
+}
+
+// newTargetScraper builds a targetScraper for target using the bind and
+// replication settings from module, registering fresh metrics against reg.
+func newTargetScraper(target string, module Module, reg *prometheus.Registry) *targetScraper {
+	netw, addr := "ldap", target
+	if u, err := url.Parse(target); err == nil && u.Scheme != "" && u.Host != "" {
+		netw, addr = u.Scheme, u.Host
+	}
+
+	ts := &targetScraper{
+		net:          netw,
+		addr:         addr,
+		user:         module.User,
+		pass:         module.Pass,
+		saslExternal: module.SASLExternal,
+		tls:          module.TLS,
+		timeout:      module.Timeout,
+		sync:         module.Sync,
+		topology:     ReplicationTopology{Peers: module.Topology},
+		log:          slog.Default().With("component", "probe", "target", target),
+	}
+	ts.queries = newProbeQueries(reg, ts.sync, module.CustomQueries, ts.log)
+	ts.replDelayGauge, ts.csnCountGauge, ts.csnModGauge = newTopologyGauges(reg)
+	return ts
+}
+
+func (ts *targetScraper) queryTimeout() time.Duration {
+	if ts.timeout > 0 {
+		return ts.timeout
+	}
+	return defaultQueryTimeout
+}
+
+// newProbeQueries builds a fresh copy of the standard query set, including
+// one replication query per sync base DN and one query per configured
+// CustomQuery, with gauges registered against reg so one probe's metrics
+// never mix with another's.
+func newProbeQueries(reg *prometheus.Registry, sync []string, custom []CustomQuery, logger *slog.Logger) []*query {
+	moGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "openldap",
+		Name:      "monitored_object",
+		Help:      help(baseDN, objectClass(monitoredObject), monitoredInfo),
+	}, []string{"dn"})
+	mcGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "openldap",
+		Name:      "monitor_counter_object",
+		Help:      help(baseDN, objectClass(monitorCounterObject), monitorCounter),
+	}, []string{"dn"})
+	opGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "openldap",
+		Name:      "monitor_operation",
+		Help:      help(opsBaseDN, objectClass(monitorOperation), monitorOpCompleted),
+	}, []string{"dn"})
+	replGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "openldap",
+		Name:      "monitor_replication",
+		Help:      help(baseDN, monitorReplication),
+	}, []string{"id", "type"})
+
+	reg.MustRegister(moGauge, mcGauge, opGauge, replGauge)
+
+	queries := []*query{
+		{baseDN: baseDN, searchFilter: objectClass(monitoredObject), searchAttr: monitoredInfo, metric: moGauge, setData: setValue},
+		{baseDN: baseDN, searchFilter: objectClass(monitorCounterObject), searchAttr: monitorCounter, metric: mcGauge, setData: setValue},
+		{baseDN: opsBaseDN, searchFilter: objectClass(monitorOperation), searchAttr: monitorOpCompleted, metric: opGauge, setData: setValue},
+	}
+	for _, base := range sync {
+		queries = append(queries, &query{
+			baseDN:       base,
+			searchFilter: objectClass("*"),
+			searchAttr:   monitorReplicationFilter,
+			metric:       replGauge,
+			setData:      setReplicationValue,
+			logger:       logger,
+		})
+	}
+	for _, cq := range custom {
+		gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "openldap",
+			Name:      cq.Name,
+			Help:      customQueryHelp(cq),
+		}, []string{"dn"})
+		reg.MustRegister(gauge)
+		queries = append(queries, &query{
+			baseDN:       cq.BaseDN,
+			searchFilter: cq.Filter,
+			searchAttr:   cq.Attr,
+			metric:       gauge,
+			setData:      setValue,
+		})
+	}
+	return queries
+}
+
+// scrape dials the target once, runs every query against it, and reports
+// whether the whole probe succeeded.
+func (ts *targetScraper) scrape() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), ts.queryTimeout())
+	defer cancel()
+
+	conn, _, err := dialLDAP(ts.net, ts.addr, ts.tls, ts.saslExternal, ts.user, ts.pass)
+	if err != nil {
+		ts.log.Error("dial failed", "error", err)
+		return false
+	}
+	defer conn.Close()
+
+	ok := true
+	for _, q := range ts.queries {
+		if err := runQuery(ctx, conn, q); err != nil {
+			ts.log.Warn("query failed", "filter", q.searchFilter, "base_dn", q.baseDN, "error", err)
+			ok = false
+		}
+	}
+
+	if len(ts.topology.Peers) > 0 {
+		if !ts.topology.collectInto(ctx, ts.sync, ts.log, ts.replDelayGauge, ts.csnCountGauge, ts.csnModGauge) {
+			ok = false
+		}
+	}
+
+	return ok
+}
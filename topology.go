@@ -0,0 +1,230 @@
+package openldap_exporter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Peer is one member of a replication mesh to poll for contextCSN: a
+// provider (master) or consumer (replica) OpenLDAP server reachable at
+// Addr under its own bind and TLS settings. It supersedes the old single
+// provider/single consumer LdapSyncMasterAddr design, which could only
+// express one master and couldn't tell a multi-provider ring's peers
+// apart.
+type Peer struct {
+	Addr         string    `yaml:"addr"`
+	Role         string    `yaml:"role"`
+	User         string    `yaml:"user"`
+	Pass         string    `yaml:"pass"`
+	SASLExternal bool      `yaml:"sasl_external"`
+	TLS          TLSConfig `yaml:"tls"`
+}
+
+// ReplicationTopology is the full set of peers to poll once per scrape for
+// openldap_replication_delay_seconds, grouped by sid so a sync issue on
+// any provider or consumer in the mesh is visible, not just a single
+// master/replica pair.
+type ReplicationTopology struct {
+	Peers []Peer
+}
+
+func replicationDelayDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "replication", "delay_seconds"),
+		"Seconds between the newest contextCSN seen for a sid across the topology and this peer's own contextCSN for that sid",
+		[]string{"source_sid", "peer", "role"}, nil,
+	)
+}
+
+func replicationCSNCountDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "replication", "csn_count"),
+		"contextCSN change count this peer reports for the given sid",
+		[]string{"peer", "sid"}, nil,
+	)
+}
+
+func replicationCSNModDesc() *prometheus.Desc {
+	return prometheus.NewDesc(
+		prometheus.BuildFQName("openldap", "replication", "csn_mod"),
+		"contextCSN modifier id this peer reports for the given sid",
+		[]string{"peer", "sid"}, nil,
+	)
+}
+
+// peerCSN is one sid's parsed contextCSN value as reported by a single peer.
+type peerCSN struct {
+	gt, count, mod float64
+}
+
+// fetchAll dials every peer in t once, in parallel so one slow or
+// unreachable peer can't eat the shared ctx deadline before the rest are
+// even dialed, and reads its (possibly multi-valued) contextCSN under each
+// of baseDNs. It returns each peer's sid->peerCSN map (nil for a peer that
+// failed) alongside the newest gt seen per sid across the whole topology,
+// and reports whether every peer answered.
+func (t *ReplicationTopology) fetchAll(ctx context.Context, baseDNs []string, logger *slog.Logger) ([]map[string]peerCSN, map[string]float64, bool) {
+	if len(baseDNs) == 0 {
+		baseDNs = []string{baseDN}
+	}
+
+	ok := true
+	perPeer := make([]map[string]peerCSN, len(t.Peers))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := range t.Peers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			peer := &t.Peers[i]
+			defer func() {
+				// A malformed or malicious peer response must not take
+				// down the whole exporter process; treat a panic in this
+				// goroutine as a failed fetch for this peer only.
+				if r := recover(); r != nil {
+					mu.Lock()
+					defer mu.Unlock()
+					logger.Error("topology peer query panicked", "peer", peer.Addr, "role", peer.Role, "error", r)
+					ok = false
+				}
+			}()
+			csns, err := peer.fetchCSNs(ctx, baseDNs, logger)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Error("topology peer query failed", "peer", peer.Addr, "role", peer.Role, "error", err)
+				ok = false
+				return
+			}
+			perPeer[i] = csns
+		}(i)
+	}
+	wg.Wait()
+
+	return perPeer, newestGTBySID(perPeer), ok
+}
+
+// newestGTBySID scans every peer's sid->peerCSN map and returns, per sid,
+// the newest gt seen anywhere in the topology - the value each peer's own
+// gt is subtracted from to compute openldap_replication_delay_seconds.
+func newestGTBySID(perPeer []map[string]peerCSN) map[string]float64 {
+	newestGT := map[string]float64{}
+	for _, csns := range perPeer {
+		for sid, csn := range csns {
+			if csn.gt > newestGT[sid] {
+				newestGT[sid] = csn.gt
+			}
+		}
+	}
+	return newestGT
+}
+
+// collect is the Scraper.Collect-facing variant of fetchAll: it emits
+// csn_count/csn_mod per (peer, sid) plus a delay gauge per
+// (source_sid, peer, role) as prometheus.Metric values on ch.
+func (t *ReplicationTopology) collect(ctx context.Context, baseDNs []string, logger *slog.Logger, ch chan<- prometheus.Metric) bool {
+	perPeer, newestGT, ok := t.fetchAll(ctx, baseDNs, logger)
+
+	for i, csns := range perPeer {
+		peer := &t.Peers[i]
+		for sid, csn := range csns {
+			ch <- prometheus.MustNewConstMetric(replicationCSNCountDesc(), prometheus.GaugeValue, csn.count, peer.Addr, sid)
+			ch <- prometheus.MustNewConstMetric(replicationCSNModDesc(), prometheus.GaugeValue, csn.mod, peer.Addr, sid)
+			ch <- prometheus.MustNewConstMetric(replicationDelayDesc(), prometheus.GaugeValue, newestGT[sid]-csn.gt, sid, peer.Addr, peer.Role)
+		}
+	}
+
+	return ok
+}
+
+// collectInto is the throwaway-registry variant of fetchAll used by
+// target.go's per-/probe-request scrapes, writing into GaugeVecs instead
+// of building prometheus.Metric values directly.
+func (t *ReplicationTopology) collectInto(ctx context.Context, baseDNs []string, logger *slog.Logger, delay, count, mod *prometheus.GaugeVec) bool {
+	perPeer, newestGT, ok := t.fetchAll(ctx, baseDNs, logger)
+
+	for i, csns := range perPeer {
+		peer := &t.Peers[i]
+		for sid, csn := range csns {
+			count.WithLabelValues(peer.Addr, sid).Set(csn.count)
+			mod.WithLabelValues(peer.Addr, sid).Set(csn.mod)
+			delay.WithLabelValues(sid, peer.Addr, peer.Role).Set(newestGT[sid] - csn.gt)
+		}
+	}
+
+	return ok
+}
+
+// newTopologyGauges builds the GaugeVec trio backing ReplicationTopology's
+// metrics for a single /probe request and registers them against reg.
+func newTopologyGauges(reg *prometheus.Registry) (delay, count, mod *prometheus.GaugeVec) {
+	delay = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "openldap",
+		Subsystem: "replication",
+		Name:      "delay_seconds",
+		Help:      "Seconds between the newest contextCSN seen for a sid across the topology and this peer's own contextCSN for that sid",
+	}, []string{"source_sid", "peer", "role"})
+	count = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "openldap",
+		Subsystem: "replication",
+		Name:      "csn_count",
+		Help:      "contextCSN change count this peer reports for the given sid",
+	}, []string{"peer", "sid"})
+	mod = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "openldap",
+		Subsystem: "replication",
+		Name:      "csn_mod",
+		Help:      "contextCSN modifier id this peer reports for the given sid",
+	}, []string{"peer", "sid"})
+
+	reg.MustRegister(delay, count, mod)
+	return delay, count, mod
+}
+
+// fetchCSNs dials p fresh — topology peers are polled once per scrape
+// rather than pooled like the primary target connection — and reads every
+// contextCSN value under baseDNs, parsing each entry's possibly
+// multi-valued contextCSN into one peerCSN per sid.
+func (p *Peer) fetchCSNs(ctx context.Context, baseDNs []string, logger *slog.Logger) (map[string]peerCSN, error) {
+	netw, addr := "ldap", p.Addr
+	if u, err := url.Parse(p.Addr); err == nil && u.Scheme != "" && u.Host != "" {
+		netw, addr = u.Scheme, u.Host
+	}
+
+	conn, _, err := dialLDAP(netw, addr, p.TLS, p.SASLExternal, p.User, p.Pass)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", p.Addr, err)
+	}
+	defer conn.Close()
+
+	out := map[string]peerCSN{}
+	for _, base := range baseDNs {
+		entries, err := searchEntries(ctx, conn, base, objectClass("*"), monitorReplicationFilter)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			for _, val := range entry.GetAttributeValues(monitorReplicationFilter) {
+				gt, count, sid, mod, err := parseContextCSN(logger, val, objectClass("*"), monitorReplicationFilter)
+				if err != nil {
+					continue
+				}
+				// a sid can repeat across base DNs on a multi-suffix
+				// peer; keep whichever contextCSN is newest instead of
+				// letting base DN iteration order decide silently.
+				if existing, ok := out[sid]; ok && existing.gt >= gt {
+					continue
+				}
+				out[sid] = peerCSN{gt: gt, count: count, mod: mod}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,95 @@
+package openldap_exporter
+
+import "testing"
+
+func TestParseContextCSN(t *testing.T) {
+	cases := []struct {
+		name      string
+		val       string
+		wantErr   bool
+		wantGT    float64
+		wantCount float64
+		wantSID   string
+		wantMod   float64
+	}{
+		{
+			name:      "well formed",
+			val:       "20240101000000.000000Z#000000#001#000000",
+			wantGT:    1704067200,
+			wantCount: 0,
+			wantSID:   "001",
+			wantMod:   0,
+		},
+		{
+			name:    "bad timestamp",
+			val:     "not-a-time#000000#001#000000",
+			wantErr: true,
+		},
+		{
+			name:    "too few fields",
+			val:     "20240101000000.000000Z",
+			wantErr: true,
+		},
+		{
+			name:    "too many fields",
+			val:     "20240101000000.000000Z#000000#001#000000#extra",
+			wantErr: true,
+		},
+		{
+			name:    "bad count",
+			val:     "20240101000000.000000Z#nope#001#000000",
+			wantErr: true,
+		},
+		{
+			name:    "bad mod",
+			val:     "20240101000000.000000Z#000000#001#nope",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gt, count, sid, mod, err := parseContextCSN(nil, tc.val, "(objectClass=*)", "contextCSN")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got gt=%v count=%v sid=%q mod=%v", gt, count, sid, mod)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gt != tc.wantGT || count != tc.wantCount || sid != tc.wantSID || mod != tc.wantMod {
+				t.Fatalf("got gt=%v count=%v sid=%q mod=%v, want gt=%v count=%v sid=%q mod=%v",
+					gt, count, sid, mod, tc.wantGT, tc.wantCount, tc.wantSID, tc.wantMod)
+			}
+		})
+	}
+}
+
+func TestNewestGTBySID(t *testing.T) {
+	perPeer := []map[string]peerCSN{
+		{
+			"001": {gt: 100},
+			"002": {gt: 50},
+		},
+		{
+			"001": {gt: 90},
+			"002": {gt: 75},
+			"003": {gt: 10},
+		},
+		nil, // a peer that failed to answer
+	}
+
+	got := newestGTBySID(perPeer)
+	want := map[string]float64{"001": 100, "002": 75, "003": 10}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for sid, gt := range want {
+		if got[sid] != gt {
+			t.Errorf("sid %q: got newest gt %v, want %v", sid, got[sid], gt)
+		}
+	}
+}